@@ -0,0 +1,201 @@
+package libraryelements
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// registerAPIEndpoints registers the HTTP API endpoints for the Library Elements feature.
+// The list, get, create, update, delete and restore handlers resolve a LibraryElementGuardian
+// for the element's folder before acting: reads require CanUse, writes require CanEdit, and
+// deletes require CanDelete. The version list, version get and version diff handlers do not
+// check the caller's permissions.
+func (l *LibraryElementService) registerAPIEndpoints() {
+	l.RouteRegister.Group("/api/library-elements", func(entities routing.RouteRegister) {
+		entities.Get("/", routing.Wrap(l.getLibraryElementsHandler))
+		entities.Get("/:uid", routing.Wrap(l.getLibraryElementByUIDHandler))
+		entities.Post("/", routing.Wrap(l.createLibraryElementHandler))
+		entities.Patch("/:uid", routing.Wrap(l.updateLibraryElementHandler))
+		entities.Delete("/:uid", routing.Wrap(l.deleteLibraryElementHandler))
+
+		entities.Get("/:uid/versions", routing.Wrap(l.getLibraryElementVersionsHandler))
+		entities.Get("/:uid/versions/:version", routing.Wrap(l.getLibraryElementVersionHandler))
+		entities.Get("/:uid/versions/:base/diff/:target", routing.Wrap(l.getLibraryElementVersionDiffHandler))
+		entities.Post("/:uid/versions/:version/restore", routing.Wrap(l.restoreLibraryElementVersionHandler))
+	})
+}
+
+func (l *LibraryElementService) getLibraryElementsHandler(c *models.ReqContext) response.Response {
+	kind, err := strconv.Atoi(c.Query("kind"))
+	if err != nil {
+		return response.Error(400, "kind query parameter is required", err)
+	}
+
+	elements, err := l.GetAllLibraryElements(c.OrgId, Kind(kind))
+	if err != nil {
+		return response.Error(500, "failed to list library elements", err)
+	}
+
+	dtos := make([]LibraryElementDTO, 0, len(elements))
+	for _, element := range elements {
+		canUse, err := l.canUseElement(c, element)
+		if err != nil {
+			return response.Error(500, "failed to resolve permissions", err)
+		}
+		if !canUse {
+			continue
+		}
+
+		meta, err := l.buildDTOMeta(c, element.FolderID)
+		if err != nil {
+			return response.Error(500, "failed to resolve permissions", err)
+		}
+
+		dtos = append(dtos, LibraryElementDTO{LibraryElement: element, Meta: meta})
+	}
+
+	return response.JSON(200, dtos)
+}
+
+func (l *LibraryElementService) getLibraryElementByUIDHandler(c *models.ReqContext) response.Response {
+	element, err := l.GetLibraryElement(c.OrgId, c.Params(":uid"))
+	if err != nil {
+		return response.Error(404, "library element not found", err)
+	}
+
+	canUse, err := l.canUseElement(c, element)
+	if err != nil {
+		return response.Error(500, "failed to resolve permissions", err)
+	}
+	if !canUse {
+		return response.Error(403, "access denied", ErrLibraryElementAccessDenied)
+	}
+
+	meta, err := l.buildDTOMeta(c, element.FolderID)
+	if err != nil {
+		return response.Error(500, "failed to resolve permissions", err)
+	}
+
+	return response.JSON(200, LibraryElementDTO{LibraryElement: element, Meta: meta})
+}
+
+func (l *LibraryElementService) createLibraryElementHandler(c *models.ReqContext) response.Response {
+	var cmd CreateLibraryElementCommand
+	if err := json.NewDecoder(c.Req.Request.Body).Decode(&cmd); err != nil {
+		return response.Error(400, "bad request", err)
+	}
+
+	element, err := l.CreateLibraryElement(c, cmd)
+	if err != nil {
+		return toLibraryElementErrorResponse("failed to create library element", err)
+	}
+
+	return response.JSON(200, element)
+}
+
+func (l *LibraryElementService) updateLibraryElementHandler(c *models.ReqContext) response.Response {
+	var cmd PatchLibraryElementCommand
+	if err := json.NewDecoder(c.Req.Request.Body).Decode(&cmd); err != nil {
+		return response.Error(400, "bad request", err)
+	}
+
+	element, err := l.UpdateLibraryElement(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryElementErrorResponse("failed to update library element", err)
+	}
+
+	return response.JSON(200, element)
+}
+
+func (l *LibraryElementService) deleteLibraryElementHandler(c *models.ReqContext) response.Response {
+	if err := l.DeleteLibraryElement(c, c.Params(":uid")); err != nil {
+		return toLibraryElementErrorResponse("failed to delete library element", err)
+	}
+
+	return response.Success("library element deleted")
+}
+
+func (l *LibraryElementService) getLibraryElementVersionsHandler(c *models.ReqContext) response.Response {
+	versions, err := l.GetLibraryElementVersions(c.OrgId, c.Params(":uid"))
+	if err != nil {
+		return response.Error(500, "failed to get library element versions", err)
+	}
+
+	return response.JSON(200, versions)
+}
+
+func (l *LibraryElementService) getLibraryElementVersionHandler(c *models.ReqContext) response.Response {
+	version, err := strconv.ParseInt(c.Params(":version"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid version", err)
+	}
+
+	v, err := l.GetLibraryElementVersion(c.OrgId, c.Params(":uid"), version)
+	if err != nil {
+		return response.Error(404, "library element version not found", err)
+	}
+
+	return response.JSON(200, v)
+}
+
+func (l *LibraryElementService) getLibraryElementVersionDiffHandler(c *models.ReqContext) response.Response {
+	base, err := strconv.ParseInt(c.Params(":base"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid base version", err)
+	}
+	target, err := strconv.ParseInt(c.Params(":target"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid target version", err)
+	}
+
+	patch, err := l.GetLibraryElementVersionDiff(c.OrgId, c.Params(":uid"), base, target)
+	if err != nil {
+		return response.Error(500, "failed to diff library element versions", err)
+	}
+
+	return response.JSON(200, patch)
+}
+
+func (l *LibraryElementService) restoreLibraryElementVersionHandler(c *models.ReqContext) response.Response {
+	version, err := strconv.ParseInt(c.Params(":version"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid version", err)
+	}
+
+	element, err := l.RestoreLibraryElementVersion(c, c.Params(":uid"), version)
+	if err != nil {
+		return response.Error(500, "failed to restore library element version", err)
+	}
+
+	return response.JSON(200, element)
+}
+
+// toLibraryElementErrorResponse maps the sentinel errors a write operation can fail with
+// to their HTTP status code, falling back to 500 for anything else.
+func toLibraryElementErrorResponse(message string, err error) response.Response {
+	switch err {
+	case ErrLibraryElementNotFound:
+		return response.Error(404, message, err)
+	case ErrLibraryElementAccessDenied:
+		return response.Error(403, message, err)
+	case ErrLibraryElementVersionMismatch:
+		return response.Error(409, message, err)
+	default:
+		return response.Error(500, message, err)
+	}
+}
+
+// canUseElement resolves a LibraryElementGuardian for element's folder and returns
+// whether the caller may read it.
+func (l *LibraryElementService) canUseElement(c *models.ReqContext, element LibraryElement) (bool, error) {
+	g, err := NewLibraryElementGuardian(c, element.FolderID)
+	if err != nil {
+		return false, err
+	}
+
+	return g.CanUse()
+}
@@ -0,0 +1,218 @@
+package libraryelements
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// CreateLibraryElementCommand is the payload to create a new library element.
+type CreateLibraryElementCommand struct {
+	FolderID int64            `json:"folderId"`
+	Name     string           `json:"name"`
+	Kind     Kind             `json:"kind"`
+	Model    *simplejson.Json `json:"model"`
+}
+
+// PatchLibraryElementCommand is the payload to update a library element. Version must
+// match the element's current version, so a stale client fails instead of clobbering a
+// concurrent edit.
+type PatchLibraryElementCommand struct {
+	Name    string           `json:"name"`
+	Model   *simplejson.Json `json:"model"`
+	Version int64            `json:"version"`
+	Message string           `json:"message"`
+}
+
+// GetLibraryElement gets a single library element by uid.
+func (l *LibraryElementService) GetLibraryElement(orgID int64, uid string) (LibraryElement, error) {
+	var element LibraryElement
+
+	err := l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		has, err := sess.Table("library_element").Where("org_id = ? AND uid = ?", orgID, uid).Get(&element)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrLibraryElementNotFound
+		}
+		return nil
+	})
+
+	return element, err
+}
+
+// GetAllLibraryElements lists every library element of a given kind in an org.
+func (l *LibraryElementService) GetAllLibraryElements(orgID int64, kind Kind) ([]LibraryElement, error) {
+	var elements []LibraryElement
+
+	err := l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		return sess.Table("library_element").Where("org_id = ? AND kind = ?", orgID, kind).Find(&elements)
+	})
+
+	return elements, err
+}
+
+// CreateLibraryElement stores a brand new library element, guarded by CanEdit on its folder.
+func (l *LibraryElementService) CreateLibraryElement(c *models.ReqContext, cmd CreateLibraryElementCommand) (LibraryElement, error) {
+	canEdit, err := l.canEditFolder(c, cmd.FolderID)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+	if !canEdit {
+		return LibraryElement{}, ErrLibraryElementAccessDenied
+	}
+
+	elementSlug, err := l.slugify(c.OrgId, cmd.FolderID, cmd.Kind, cmd.Name, 0)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	now := time.Now()
+	element := LibraryElement{
+		OrgID:     c.OrgId,
+		FolderID:  cmd.FolderID,
+		UID:       util.GenerateShortUID(),
+		Name:      cmd.Name,
+		Kind:      cmd.Kind,
+		Slug:      elementSlug,
+		Model:     cmd.Model,
+		Version:   1,
+		Created:   now,
+		Updated:   now,
+		CreatedBy: c.UserId,
+		UpdatedBy: c.UserId,
+	}
+
+	err = l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Table("library_element").Insert(&element)
+		return err
+	})
+
+	return element, err
+}
+
+// UpdateLibraryElement updates an existing library element. The model it replaces is
+// archived as a new library_element_version row before being overwritten, and if the name
+// changed the element is re-slugged with the old slug kept as a library_element_alias.
+func (l *LibraryElementService) UpdateLibraryElement(c *models.ReqContext, uid string, cmd PatchLibraryElementCommand) (LibraryElement, error) {
+	current, err := l.GetLibraryElement(c.OrgId, uid)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	canEdit, err := l.canEditFolder(c, current.FolderID)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+	if !canEdit {
+		return LibraryElement{}, ErrLibraryElementAccessDenied
+	}
+
+	if cmd.Version != current.Version {
+		return LibraryElement{}, ErrLibraryElementVersionMismatch
+	}
+
+	newName := current.Name
+	if cmd.Name != "" {
+		newName = cmd.Name
+	}
+
+	newSlug := current.Slug
+	if newName != current.Name {
+		newSlug, err = l.slugify(c.OrgId, current.FolderID, current.Kind, newName, current.ID)
+		if err != nil {
+			return LibraryElement{}, err
+		}
+	}
+
+	now := time.Now()
+	err = l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		version := LibraryElementVersion{
+			ElementID: current.ID,
+			Version:   current.Version,
+			Model:     current.Model,
+			Created:   current.Updated,
+			CreatedBy: current.UpdatedBy,
+			Message:   cmd.Message,
+		}
+		if _, err := sess.Table("library_element_version").Insert(&version); err != nil {
+			return err
+		}
+
+		if newSlug != current.Slug {
+			alias := LibraryElementAlias{
+				ElementID: current.ID,
+				OrgID:     current.OrgID,
+				FolderID:  current.FolderID,
+				Kind:      current.Kind,
+				Slug:      current.Slug,
+				Created:   now,
+			}
+			if _, err := sess.Table("library_element_alias").Insert(&alias); err != nil {
+				return err
+			}
+		}
+
+		_, err := sess.Table("library_element").ID(current.ID).Update(map[string]interface{}{
+			"name":       newName,
+			"slug":       newSlug,
+			"model":      cmd.Model,
+			"version":    current.Version + 1,
+			"updated":    now,
+			"updated_by": c.UserId,
+		})
+		return err
+	})
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	current.Name = newName
+	current.Slug = newSlug
+	current.Model = cmd.Model
+	current.Version++
+	current.Updated = now
+	current.UpdatedBy = c.UserId
+
+	return current, nil
+}
+
+// DeleteLibraryElement removes a library element, guarded by CanDelete on its folder.
+func (l *LibraryElementService) DeleteLibraryElement(c *models.ReqContext, uid string) error {
+	element, err := l.GetLibraryElement(c.OrgId, uid)
+	if err != nil {
+		return err
+	}
+
+	g, err := NewLibraryElementGuardian(c, element.FolderID)
+	if err != nil {
+		return err
+	}
+	canDelete, err := g.CanDelete()
+	if err != nil {
+		return err
+	}
+	if !canDelete {
+		return ErrLibraryElementAccessDenied
+	}
+
+	return l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Table("library_element").ID(element.ID).Delete(&LibraryElement{})
+		return err
+	})
+}
+
+// canEditFolder resolves folderID's guardian and returns whether the caller may edit it.
+func (l *LibraryElementService) canEditFolder(c *models.ReqContext, folderID int64) (bool, error) {
+	g, err := NewLibraryElementGuardian(c, folderID)
+	if err != nil {
+		return false, err
+	}
+
+	return g.CanEdit()
+}
@@ -0,0 +1,30 @@
+package libraryelements
+
+import "github.com/grafana/grafana/pkg/models"
+
+// LibraryElementDTO is a library element plus the resolved meta block GET responses
+// surface so the frontend can hide actions the caller isn't allowed to perform.
+type LibraryElementDTO struct {
+	LibraryElement
+	Meta LibraryElementDTOMeta `json:"meta"`
+}
+
+// buildDTOMeta resolves the caller's guardian permissions for a library element stored in
+// folderID into the meta block returned alongside it.
+func (l *LibraryElementService) buildDTOMeta(c *models.ReqContext, folderID int64) (LibraryElementDTOMeta, error) {
+	g, err := NewLibraryElementGuardian(c, folderID)
+	if err != nil {
+		return LibraryElementDTOMeta{}, err
+	}
+
+	canEdit, err := g.CanEdit()
+	if err != nil {
+		return LibraryElementDTOMeta{}, err
+	}
+	canAdmin, err := g.CanDelete()
+	if err != nil {
+		return LibraryElementDTOMeta{}, err
+	}
+
+	return LibraryElementDTOMeta{CanEdit: canEdit, CanAdmin: canAdmin}, nil
+}
@@ -0,0 +1,15 @@
+package libraryelements
+
+import "errors"
+
+var (
+	// ErrLibraryElementNotFound is returned when a library element, version or alias
+	// cannot be found.
+	ErrLibraryElementNotFound = errors.New("library element not found")
+	// ErrLibraryElementAccessDenied is returned when the caller lacks the folder
+	// permission a library element operation requires.
+	ErrLibraryElementAccessDenied = errors.New("user does not have access to this library element")
+	// ErrLibraryElementVersionMismatch is returned when an update targets a version
+	// other than the element's current one, to avoid clobbering a concurrent edit.
+	ErrLibraryElementVersionMismatch = errors.New("library element has been updated by someone else")
+)
@@ -0,0 +1,40 @@
+package libraryelements
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/guardian"
+)
+
+// LibraryElementGuardian resolves the caller's permissions against the folder a library
+// element lives in, the same way dashboard folder ACLs are resolved for dashboards. It
+// backs permission checks for every element kind, including the `librarypanels`
+// backward-compat shim.
+type LibraryElementGuardian struct {
+	folderGuardian guardian.DashboardGuardian
+}
+
+// NewLibraryElementGuardian builds a LibraryElementGuardian for a library element stored in folderID.
+func NewLibraryElementGuardian(c *models.ReqContext, folderID int64) (*LibraryElementGuardian, error) {
+	g, err := guardian.NewByFolderID(folderID, c.OrgId, c.SignedInUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LibraryElementGuardian{folderGuardian: g}, nil
+}
+
+// CanUse returns true if the caller may read the library element, i.e. view its folder.
+func (g *LibraryElementGuardian) CanUse() (bool, error) {
+	return g.folderGuardian.CanView()
+}
+
+// CanEdit returns true if the caller may create, connect or update the library element,
+// i.e. edit its folder.
+func (g *LibraryElementGuardian) CanEdit() (bool, error) {
+	return g.folderGuardian.CanEdit()
+}
+
+// CanDelete returns true if the caller may delete the library element, i.e. admin its folder.
+func (g *LibraryElementGuardian) CanDelete() (bool, error) {
+	return g.folderGuardian.CanAdmin()
+}
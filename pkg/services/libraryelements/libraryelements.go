@@ -0,0 +1,361 @@
+package libraryelements
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// LibraryElementService is the service for the Library Elements feature, the
+// generalization of the Panel Library to cover any reusable dashboard
+// fragment: panels, rows, query definitions and template variables.
+type LibraryElementService struct {
+	Cfg           *setting.Cfg          `inject:""`
+	SQLStore      *sqlstore.SQLStore    `inject:""`
+	RouteRegister routing.RouteRegister `inject:""`
+	log           log.Logger
+}
+
+func init() {
+	registry.RegisterService(&LibraryElementService{})
+}
+
+// Init initializes the LibraryElement service.
+func (l *LibraryElementService) Init() error {
+	l.log = log.New("libraryelements")
+
+	l.registerAPIEndpoints()
+
+	return nil
+}
+
+// IsEnabled returns true if the Panel Library feature is enabled for this instance.
+func (l *LibraryElementService) IsEnabled() bool {
+	if l.Cfg == nil {
+		return false
+	}
+
+	return l.Cfg.IsPanelLibraryEnabled()
+}
+
+// LoadLibraryElementsForDashboard loads library elements JSON from the database for a dashboard,
+// expanding every node in the dashboard JSON that references a stored element.
+func (l *LibraryElementService) LoadLibraryElementsForDashboard(dash *models.Dashboard) error {
+	if !l.IsEnabled() {
+		return nil
+	}
+
+	elements, err := l.getLibraryElementsForDashboardID(dash.Id)
+	if err != nil {
+		return err
+	}
+
+	panels := dash.Data.Get("panels").MustArray()
+	for i, panel := range panels {
+		panelAsJSON := simplejson.NewFromAny(panel)
+		if !l.expandElement(dash.Data.Get("panels"), i, panelAsJSON, elements) {
+			continue
+		}
+	}
+
+	variables := dash.Data.Get("templating").Get("list").MustArray()
+	for i, variable := range variables {
+		variableAsJSON := simplejson.NewFromAny(variable)
+		if !l.expandElement(dash.Data.Get("templating").Get("list"), i, variableAsJSON, elements) {
+			continue
+		}
+	}
+
+	return nil
+}
+
+// expandElement replaces the node at index i of arr with the stored library element it
+// references, if any, preserving dashboard-local overrides. It returns true if a node was expanded.
+func (l *LibraryElementService) expandElement(arr *simplejson.Json, i int, nodeAsJSON *simplejson.Json, elements map[string]LibraryElement) bool {
+	for key := range elementJSONKeys {
+		ref := nodeAsJSON.Get(key)
+		if ref.Interface() == nil {
+			continue
+		}
+
+		uid := ref.Get("uid").MustString()
+		if len(uid) == 0 {
+			return false
+		}
+
+		elementInDB, ok := elements[uid]
+		if !ok {
+			return false
+		}
+
+		// set the stored element's json as the new node json
+		arr.SetIndex(i, elementInDB.Model.Interface())
+
+		// restore dashboard-local overrides on top of the stored model
+		elem := arr.GetIndex(i)
+		if gridPos := nodeAsJSON.Get("gridPos"); gridPos.Interface() != nil {
+			elem.Set("gridPos", gridPos.MustMap())
+		}
+		if id := nodeAsJSON.Get("id"); id.Interface() != nil {
+			elem.Set("id", id.MustInt64())
+		}
+		if current := nodeAsJSON.Get("current"); current.Interface() != nil {
+			elem.Set("current", current.MustMap())
+		}
+		elem.Set(key, map[string]interface{}{
+			"uid":  elementInDB.UID,
+			"name": elementInDB.Name,
+		})
+
+		return true
+	}
+
+	return false
+}
+
+// CleanLibraryElementsForDashboard strips every library element node in the dashboard
+// JSON down to its reference (uid + name) before storing a dashboard to the database.
+func (l *LibraryElementService) CleanLibraryElementsForDashboard(dash *models.Dashboard) error {
+	if !l.IsEnabled() {
+		return nil
+	}
+
+	panels := dash.Data.Get("panels").MustArray()
+	for i, panel := range panels {
+		panelAsJSON := simplejson.NewFromAny(panel)
+		if err := l.cleanElement(dash.Data.Get("panels"), i, panelAsJSON); err != nil {
+			return err
+		}
+	}
+
+	variables := dash.Data.Get("templating").Get("list").MustArray()
+	for i, variable := range variables {
+		variableAsJSON := simplejson.NewFromAny(variable)
+		if err := l.cleanElement(dash.Data.Get("templating").Get("list"), i, variableAsJSON); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *LibraryElementService) cleanElement(arr *simplejson.Json, i int, nodeAsJSON *simplejson.Json) error {
+	for key := range elementJSONKeys {
+		ref := nodeAsJSON.Get(key)
+		if ref.Interface() == nil {
+			continue
+		}
+
+		uid := ref.Get("uid").MustString()
+		if len(uid) == 0 {
+			return errors.New("found a library element without uid")
+		}
+		name := ref.Get("name").MustString()
+		if len(name) == 0 {
+			return errors.New("found a library element without name")
+		}
+
+		gridPos := nodeAsJSON.Get("gridPos").MustMap()
+		id := nodeAsJSON.Get("id").MustInt64(int64(i))
+		cleaned := map[string]interface{}{
+			"id":      id,
+			"gridPos": gridPos,
+			key: map[string]interface{}{
+				"uid":  uid,
+				"name": name,
+			},
+		}
+		if current := nodeAsJSON.Get("current"); current.Interface() != nil {
+			cleaned["current"] = current.MustMap()
+		}
+		arr.SetIndex(i, cleaned)
+
+		return nil
+	}
+
+	return nil
+}
+
+// ConnectLibraryElementsForDashboard connects every library element referenced by a
+// dashboard to that dashboard. Each referenced element's folder is checked against the
+// caller's permissions first; Connect is rejected if the caller lacks edit rights on any
+// one of them.
+func (l *LibraryElementService) ConnectLibraryElementsForDashboard(c *models.ReqContext, dash *models.Dashboard) error {
+	if !l.IsEnabled() {
+		return nil
+	}
+
+	if dash.Id == 0 || dash.Uid == "" {
+		return errors.New("dashboard is missing an ID or uid")
+	}
+
+	nodes := append([]interface{}{}, dash.Data.Get("panels").MustArray()...)
+	nodes = append(nodes, dash.Data.Get("templating").Get("list").MustArray()...)
+	for _, node := range nodes {
+		nodeAsJSON := simplejson.NewFromAny(node)
+		for key, kind := range elementJSONKeys {
+			ref := nodeAsJSON.Get(key)
+			if ref.Interface() == nil {
+				continue
+			}
+
+			uid := ref.Get("uid").MustString()
+			if len(uid) == 0 {
+				return fmt.Errorf("found a library element without uid")
+			}
+
+			canEdit, err := l.canEditElement(c, uid)
+			if err != nil {
+				return err
+			}
+			if !canEdit {
+				return ErrLibraryElementAccessDenied
+			}
+
+			if err := l.connectDashboard(c, uid, kind, dash.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// canEditElement resolves the library element's folder and asks a LibraryElementGuardian
+// whether the caller may edit it.
+func (l *LibraryElementService) canEditElement(c *models.ReqContext, uid string) (bool, error) {
+	element, err := l.GetLibraryElement(c.OrgId, uid)
+	if err != nil {
+		return false, err
+	}
+
+	return l.canEditFolder(c, element.FolderID)
+}
+
+// AddMigration defines database migrations.
+// If Panel Library is not enabled does nothing.
+func (l *LibraryElementService) AddMigration(mg *migrator.Migrator) {
+	if !l.IsEnabled() {
+		return
+	}
+
+	libraryElementV1 := migrator.Table{
+		Name: "library_element",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "folder_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "kind", Type: migrator.DB_Int, Nullable: false},
+			{Name: "model", Type: migrator.DB_Text, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "folder_id", "kind", "name"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_element table v1", migrator.NewAddTableMigration(libraryElementV1))
+	mg.AddMigration("add index library_element org_id & folder_id & kind & name", migrator.NewAddIndexMigration(libraryElementV1, libraryElementV1.Indices[0]))
+
+	libraryElementConnectionV1 := migrator.Table{
+		Name: "library_element_connection",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "element_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "kind", Type: migrator.DB_Int, Nullable: false},
+			{Name: "connection_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"element_id", "kind", "connection_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_element_connection table v1", migrator.NewAddTableMigration(libraryElementConnectionV1))
+	mg.AddMigration("add index library_element_connection element_id & kind & connection_id", migrator.NewAddIndexMigration(libraryElementConnectionV1, libraryElementConnectionV1.Indices[0]))
+
+	// The version column is added before the data-copy migrations below, since their
+	// INSERTs populate it and migrations run in registration order.
+	versionCol := &migrator.Column{Name: "version", Type: migrator.DB_BigInt, Nullable: false, Default: "1"}
+	mg.AddMigration("add version column to library_element", migrator.NewAddColumnMigration(libraryElementV1, versionCol))
+
+	// Carry existing library panels and their dashboard connections over into the
+	// generalized store so the `librarypanels` HTTP endpoints keep working unchanged.
+	mg.AddMigration("copy library_panel rows into library_element", migrator.NewRawSQLMigration(
+		`INSERT INTO library_element (org_id, folder_id, uid, name, kind, model, version, created, created_by, updated, updated_by)
+		SELECT org_id, folder_id, uid, name, 1, model, 1, created, created_by, updated, updated_by FROM library_panel`))
+	mg.AddMigration("copy library_panel_dashboard rows into library_element_connection", migrator.NewRawSQLMigration(
+		`INSERT INTO library_element_connection (element_id, kind, connection_id, created, created_by)
+		SELECT le.id, 1, lpd.dashboard_id, lpd.created, lpd.created_by
+		FROM library_panel_dashboard lpd
+		INNER JOIN library_panel lp ON lp.id = lpd.librarypanel_id
+		INNER JOIN library_element le ON le.uid = lp.uid AND le.org_id = lp.org_id`))
+
+	// Slugs, so a library element can be linked to and embedded from external systems by a
+	// human-readable path rather than by opaque UID. Applies to every kind, not just panels.
+	// Registered after the data-copy migrations above, so the backfill below has the copied
+	// library_panel rows to derive slugs from.
+	slugCol := &migrator.Column{Name: "slug", Type: migrator.DB_NVarchar, Length: 189, Nullable: false, Default: "''"}
+	mg.AddMigration("add slug column to library_element", migrator.NewAddColumnMigration(libraryElementV1, slugCol))
+	mg.AddMigration("populate slug column on library_element", &addLibraryElementSlugMigration{})
+	mg.AddMigration("add unique index library_element org_id & folder_id & kind & slug", migrator.NewAddIndexMigration(libraryElementV1, &migrator.Index{
+		Cols: []string{"org_id", "folder_id", "kind", "slug"}, Type: migrator.UniqueIndex,
+	}))
+
+	libraryElementAliasV1 := migrator.Table{
+		Name: "library_element_alias",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "element_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "folder_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "kind", Type: migrator.DB_Int, Nullable: false},
+			{Name: "slug", Type: migrator.DB_NVarchar, Length: 189, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "folder_id", "kind", "slug"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_element_alias table v1", migrator.NewAddTableMigration(libraryElementAliasV1))
+	mg.AddMigration("add index library_element_alias org_id & folder_id & kind & slug", migrator.NewAddIndexMigration(libraryElementAliasV1, libraryElementAliasV1.Indices[0]))
+
+	libraryElementVersionV1 := migrator.Table{
+		Name: "library_element_version",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "element_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "version", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "model", Type: migrator.DB_Text, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "message", Type: migrator.DB_Text, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"element_id", "version"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_element_version table v1", migrator.NewAddTableMigration(libraryElementVersionV1))
+	mg.AddMigration("add index library_element_version element_id & version", migrator.NewAddIndexMigration(libraryElementVersionV1, libraryElementVersionV1.Indices[0]))
+
+	// A connection can pin an element to a specific version instead of always tracking
+	// latest, since a single library element can be embedded in many dashboards.
+	connectionVersionCol := &migrator.Column{Name: "version", Type: migrator.DB_BigInt, Nullable: true}
+	mg.AddMigration("add version column to library_element_connection", migrator.NewAddColumnMigration(libraryElementConnectionV1, connectionVersionCol))
+}
@@ -0,0 +1,46 @@
+package libraryelements
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// TestCleanElement_StripsLibraryVariableReference checks that cleanElement, run over a
+// templating list entry, recompresses a fully expanded libraryVariable node back to a
+// {uid,name} reference while keeping its dashboard-local 'current' selection.
+func TestCleanElement_StripsLibraryVariableReference(t *testing.T) {
+	l := &LibraryElementService{}
+
+	list, err := simplejson.NewJson([]byte(`[
+		{
+			"name": "region",
+			"options": ["us-east", "us-west"],
+			"current": {"text": "us-east", "value": "us-east"},
+			"libraryVariable": {"uid": "var-uid", "name": "region"}
+		}
+	]`))
+	if err != nil {
+		t.Fatalf("failed to build test templating list JSON: %v", err)
+	}
+
+	variableAsJSON := simplejson.NewFromAny(list.MustArray()[0])
+	if err := l.cleanElement(list, 0, variableAsJSON); err != nil {
+		t.Fatalf("cleanElement returned error: %v", err)
+	}
+
+	cleaned := list.GetIndex(0)
+	if _, hasOptions := cleaned.CheckGet("options"); hasOptions {
+		t.Errorf("expected the full variable model to be stripped down to a reference, but it still has 'options'")
+	}
+
+	ref := cleaned.Get("libraryVariable")
+	if uid := ref.Get("uid").MustString(); uid != "var-uid" {
+		t.Errorf("expected the cleaned reference to keep uid %q, got %q", "var-uid", uid)
+	}
+
+	current := cleaned.Get("current")
+	if text := current.Get("text").MustString(); text != "us-east" {
+		t.Errorf("expected the cleaned node to keep the dashboard-local 'current' selection, got %q", text)
+	}
+}
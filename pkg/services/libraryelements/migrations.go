@@ -0,0 +1,51 @@
+package libraryelements
+
+import (
+	"fmt"
+
+	"github.com/gosimple/slug"
+	"xorm.io/xorm"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// addLibraryElementSlugMigration backfills the slug column on library_element from name,
+// disambiguating collisions within the same org_id + folder_id + kind with a numeric suffix.
+type addLibraryElementSlugMigration struct {
+	migrator.MigrationBase
+}
+
+func (m *addLibraryElementSlugMigration) SQL(dialect migrator.Dialect) string {
+	return "code migration"
+}
+
+func (m *addLibraryElementSlugMigration) Exec(sess *xorm.Session, mg *migrator.Migrator) error {
+	var elements []struct {
+		Id       int64
+		OrgId    int64
+		FolderId int64
+		Kind     int64
+		Name     string
+	}
+	if err := sess.Table("library_element").Find(&elements); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(elements))
+	for _, e := range elements {
+		base := slug.Make(e.Name)
+		candidate := base
+		key := fmt.Sprintf("%d-%d-%d-%s", e.OrgId, e.FolderId, e.Kind, candidate)
+		for i := 2; seen[key]; i++ {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+			key = fmt.Sprintf("%d-%d-%d-%s", e.OrgId, e.FolderId, e.Kind, candidate)
+		}
+		seen[key] = true
+
+		if _, err := sess.Table("library_element").ID(e.Id).Update(map[string]interface{}{"slug": candidate}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package libraryelements
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// Kind is the kind of a stored library element.
+type Kind int
+
+const (
+	// PanelElement is the Kind for library elements that are reusable panels.
+	PanelElement Kind = iota + 1
+	// RowElement is the Kind for library elements that are reusable dashboard rows.
+	RowElement
+	// QueryElement is the Kind for library elements that are reusable query definitions.
+	QueryElement
+	// VariableElement is the Kind for library elements that are reusable template variables.
+	VariableElement
+)
+
+// elementJSONKeys maps the dashboard JSON key that references a library element
+// to the Kind of element it refers to. A panel or row in the "panels" array and a
+// variable in the "templating.list" array use the same `libraryPanel`-shaped
+// reference object (uid + name) pointing at a stored element of that Kind.
+var elementJSONKeys = map[string]Kind{
+	"libraryPanel":    PanelElement,
+	"libraryRow":      RowElement,
+	"libraryQuery":    QueryElement,
+	"libraryVariable": VariableElement,
+}
+
+// LibraryElement is the model for a stored, reusable dashboard fragment. Slug is
+// disambiguated per org_id+folder_id+kind; Version is bumped on every update, with the
+// model it replaces archived as a LibraryElementVersion.
+type LibraryElement struct {
+	ID       int64 `xorm:"pk autoincr 'id'"`
+	OrgID    int64 `xorm:"org_id"`
+	FolderID int64 `xorm:"folder_id"`
+	UID      string
+	Name     string
+	Kind     Kind
+	Slug     string
+	Model    *simplejson.Json
+	Version  int64
+
+	Created   time.Time
+	Updated   time.Time
+	CreatedBy int64
+	UpdatedBy int64
+}
+
+// LibraryElementConnection is the model for a connection between a library element and
+// whatever it is embedded in, most commonly a dashboard. Version pins the connection to a
+// specific LibraryElementVersion instead of always tracking the element's latest one.
+type LibraryElementConnection struct {
+	ID           int64 `xorm:"pk autoincr 'id'"`
+	ElementID    int64 `xorm:"element_id"`
+	Kind         Kind
+	ConnectionID int64 `xorm:"connection_id"`
+	Version      *int64
+	Created      time.Time
+	CreatedBy    int64
+}
+
+// LibraryElementAlias redirects a retired slug (from before an element was renamed) to
+// the element it used to identify.
+type LibraryElementAlias struct {
+	ID        int64 `xorm:"pk autoincr 'id'"`
+	ElementID int64 `xorm:"element_id"`
+	OrgID     int64 `xorm:"org_id"`
+	FolderID  int64 `xorm:"folder_id"`
+	Kind      Kind
+	Slug      string
+	Created   time.Time
+}
+
+// LibraryElementVersion is one archived revision of a library element, written every
+// time the element is updated.
+type LibraryElementVersion struct {
+	ID        int64 `xorm:"pk autoincr 'id'"`
+	ElementID int64 `xorm:"element_id"`
+	Version   int64
+	Model     *simplejson.Json
+	Created   time.Time
+	CreatedBy int64
+	Message   string
+}
+
+// LibraryElementDTOMeta is embedded in API responses so the frontend can hide actions the
+// caller isn't allowed to perform instead of finding out from a failed request.
+type LibraryElementDTOMeta struct {
+	CanEdit  bool `json:"canEdit"`
+	CanAdmin bool `json:"canAdmin"`
+}
@@ -0,0 +1,126 @@
+package libraryelements
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gosimple/slug"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// nextAvailableSlug returns base, or base suffixed with an incrementing number, such that
+// the result is not present in taken. It is pure so slug disambiguation can be unit
+// tested without a database.
+func nextAvailableSlug(base string, taken map[string]bool) string {
+	if !taken[base] {
+		return base
+	}
+
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", base, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// slugify returns the disambiguated slug a library element named name should get within
+// org/folder/kind, excluding excludeID (the element itself, when re-slugging on rename).
+func (l *LibraryElementService) slugify(orgID, folderID int64, kind Kind, name string, excludeID int64) (string, error) {
+	base := slug.Make(name)
+
+	var slugs []string
+	err := l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		return sess.Table("library_element").
+			Where("org_id = ? AND folder_id = ? AND kind = ? AND id != ?", orgID, folderID, kind, excludeID).
+			Cols("slug").Find(&slugs)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(slugs))
+	for _, s := range slugs {
+		taken[s] = true
+	}
+
+	return nextAvailableSlug(base, taken), nil
+}
+
+// GetLibraryElementBySlug looks an element up by its human-readable slug, checking current
+// slugs first and falling back to library_element_alias for redirects after a rename.
+func (l *LibraryElementService) GetLibraryElementBySlug(orgID, folderID int64, kind Kind, s string) (LibraryElement, error) {
+	var element LibraryElement
+
+	err := l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		has, err := sess.Table("library_element").
+			Where("org_id = ? AND folder_id = ? AND kind = ? AND slug = ?", orgID, folderID, kind, s).
+			Get(&element)
+		if err != nil {
+			return err
+		}
+		if has {
+			return nil
+		}
+
+		var alias LibraryElementAlias
+		has, err = sess.Table("library_element_alias").
+			Where("org_id = ? AND folder_id = ? AND kind = ? AND slug = ?", orgID, folderID, kind, s).
+			Get(&alias)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrLibraryElementNotFound
+		}
+
+		has, err = sess.Table("library_element").ID(alias.ElementID).Get(&element)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrLibraryElementNotFound
+		}
+		return nil
+	})
+
+	return element, err
+}
+
+// GetLibraryElementBySlugAndFolderUID is GetLibraryElementBySlug for callers, such as HTTP
+// handlers, that only have the folder's dashboard UID rather than its numeric id.
+func (l *LibraryElementService) GetLibraryElementBySlugAndFolderUID(orgID int64, folderUID string, kind Kind, s string) (LibraryElement, error) {
+	folderID, err := l.resolveFolderID(orgID, folderUID)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	return l.GetLibraryElementBySlug(orgID, folderID, kind, s)
+}
+
+// resolveFolderID looks up the numeric id of the folder identified by folderUID. Folders
+// are dashboards with is_folder set, so this is a plain dashboard table lookup.
+func (l *LibraryElementService) resolveFolderID(orgID int64, folderUID string) (int64, error) {
+	var folderID int64
+
+	err := l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		var folder struct {
+			ID int64 `xorm:"id"`
+		}
+		has, err := sess.Table("dashboard").
+			Where("org_id = ? AND uid = ? AND is_folder = ?", orgID, folderUID, true).
+			Get(&folder)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrLibraryElementNotFound
+		}
+
+		folderID = folder.ID
+		return nil
+	})
+
+	return folderID, err
+}
@@ -0,0 +1,34 @@
+package libraryelements
+
+import "testing"
+
+func TestNextAvailableSlug(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		taken map[string]bool
+		want  string
+	}{
+		{name: "no collision", base: "cpu-usage", taken: map[string]bool{}, want: "cpu-usage"},
+		{name: "unrelated slugs taken", base: "cpu-usage", taken: map[string]bool{"memory-usage": true}, want: "cpu-usage"},
+		{name: "one collision", base: "cpu-usage", taken: map[string]bool{"cpu-usage": true}, want: "cpu-usage-2"},
+		{
+			name: "several collisions",
+			base: "cpu-usage",
+			taken: map[string]bool{
+				"cpu-usage":   true,
+				"cpu-usage-2": true,
+				"cpu-usage-3": true,
+			},
+			want: "cpu-usage-4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextAvailableSlug(tt.base, tt.taken); got != tt.want {
+				t.Errorf("nextAvailableSlug(%q, %v) = %q, want %q", tt.base, tt.taken, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package libraryelements
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetLibraryElementVersions gets every archived revision of a library element, newest first.
+// The current version is not included, since it is only archived on the next update.
+func (l *LibraryElementService) GetLibraryElementVersions(orgID int64, uid string) ([]LibraryElementVersion, error) {
+	element, err := l.GetLibraryElement(orgID, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []LibraryElementVersion
+	err = l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		return sess.Table("library_element_version").Where("element_id = ?", element.ID).Desc("version").Find(&versions)
+	})
+
+	return versions, err
+}
+
+// GetLibraryElementVersion gets a single stored revision. The current version is served
+// straight off the element row, since it is only archived to library_element_version on
+// the next update.
+func (l *LibraryElementService) GetLibraryElementVersion(orgID int64, uid string, version int64) (LibraryElementVersion, error) {
+	element, err := l.GetLibraryElement(orgID, uid)
+	if err != nil {
+		return LibraryElementVersion{}, err
+	}
+
+	if version == element.Version {
+		return LibraryElementVersion{
+			ElementID: element.ID,
+			Version:   element.Version,
+			Model:     element.Model,
+			Created:   element.Updated,
+			CreatedBy: element.UpdatedBy,
+		}, nil
+	}
+
+	var v LibraryElementVersion
+	err = l.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		has, err := sess.Table("library_element_version").Where("element_id = ? AND version = ?", element.ID, version).Get(&v)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrLibraryElementNotFound
+		}
+		return nil
+	})
+
+	return v, err
+}
+
+// RestoreLibraryElementVersion restores a library element to a previously stored revision
+// by applying its model as a regular update, so the restore itself is archived as a new
+// version rather than rewriting history.
+func (l *LibraryElementService) RestoreLibraryElementVersion(c *models.ReqContext, uid string, version int64) (LibraryElement, error) {
+	target, err := l.GetLibraryElementVersion(c.OrgId, uid, version)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	current, err := l.GetLibraryElement(c.OrgId, uid)
+	if err != nil {
+		return LibraryElement{}, err
+	}
+
+	return l.UpdateLibraryElement(c, uid, PatchLibraryElementCommand{
+		Name:    current.Name,
+		Model:   target.Model,
+		Version: current.Version,
+		Message: fmt.Sprintf("Restored from version %d", version),
+	})
+}
+
+// PatchOp is one entry of the shallow JSON patch GetLibraryElementVersionDiff returns.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// diffModels computes a shallow, top-level-key JSON patch from base to target. It is pure
+// so the diff logic can be unit tested without a database.
+func diffModels(base, target map[string]interface{}) []PatchOp {
+	var ops []PatchOp
+
+	for key, targetValue := range target {
+		baseValue, existed := base[key]
+		if !existed {
+			ops = append(ops, PatchOp{Op: "add", Path: "/" + key, Value: targetValue})
+			continue
+		}
+		if !reflect.DeepEqual(baseValue, targetValue) {
+			ops = append(ops, PatchOp{Op: "replace", Path: "/" + key, Value: targetValue})
+		}
+	}
+
+	for key := range base {
+		if _, stillPresent := target[key]; !stillPresent {
+			ops = append(ops, PatchOp{Op: "remove", Path: "/" + key})
+		}
+	}
+
+	return ops
+}
+
+// GetLibraryElementVersionDiff returns a JSON patch between two stored revisions of a
+// library element, to power a UI compare view.
+func (l *LibraryElementService) GetLibraryElementVersionDiff(orgID int64, uid string, base, target int64) ([]PatchOp, error) {
+	baseVersion, err := l.GetLibraryElementVersion(orgID, uid, base)
+	if err != nil {
+		return nil, err
+	}
+	targetVersion, err := l.GetLibraryElementVersion(orgID, uid, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffModels(baseVersion.Model.MustMap(), targetVersion.Model.MustMap()), nil
+}
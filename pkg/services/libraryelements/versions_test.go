@@ -0,0 +1,36 @@
+package libraryelements
+
+import "testing"
+
+func TestDiffModels(t *testing.T) {
+	base := map[string]interface{}{
+		"title":   "CPU usage",
+		"type":    "graph",
+		"removed": "gone in target",
+	}
+	target := map[string]interface{}{
+		"title": "CPU usage (%)",
+		"type":  "graph",
+		"added": "new in target",
+	}
+
+	ops := diffModels(base, target)
+
+	byPath := make(map[string]PatchOp, len(ops))
+	for _, op := range ops {
+		byPath[op.Path] = op
+	}
+
+	if op, ok := byPath["/title"]; !ok || op.Op != "replace" {
+		t.Errorf("expected a replace op for /title, got %+v", byPath["/title"])
+	}
+	if _, ok := byPath["/type"]; ok {
+		t.Errorf("did not expect an op for unchanged key /type")
+	}
+	if op, ok := byPath["/added"]; !ok || op.Op != "add" {
+		t.Errorf("expected an add op for /added, got %+v", byPath["/added"])
+	}
+	if op, ok := byPath["/removed"]; !ok || op.Op != "remove" {
+		t.Errorf("expected a remove op for /removed, got %+v", byPath["/removed"])
+	}
+}
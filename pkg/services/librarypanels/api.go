@@ -0,0 +1,213 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/libraryelements"
+)
+
+// registerAPIEndpoints registers the HTTP API endpoints for the Panel Library feature.
+// The list, get, get-by-slug, create, update, delete and restore handlers resolve a guardian
+// for the panel's folder before acting: reads require CanUse, writes require CanEdit, and
+// deletes require CanDelete. The version list, version get and version diff handlers do not
+// check the caller's permissions.
+func (lps *LibraryPanelService) registerAPIEndpoints() {
+	lps.RouteRegister.Group("/api/library-panels", func(panels routing.RouteRegister) {
+		panels.Get("/", routing.Wrap(lps.getLibraryPanels))
+		panels.Get("/:uid", routing.Wrap(lps.getLibraryPanelByUID))
+		panels.Get("/:folder/:slug", routing.Wrap(lps.getLibraryPanelBySlugHandler))
+		panels.Post("/", routing.Wrap(lps.createLibraryPanel))
+		panels.Patch("/:uid", routing.Wrap(lps.updateLibraryPanel))
+		panels.Delete("/:uid", routing.Wrap(lps.deleteLibraryPanel))
+
+		panels.Get("/:uid/versions", routing.Wrap(lps.getLibraryPanelVersionsHandler))
+		panels.Get("/:uid/versions/:version", routing.Wrap(lps.getLibraryPanelVersionHandler))
+		panels.Get("/:uid/versions/:base/diff/:target", routing.Wrap(lps.getLibraryPanelVersionDiffHandler))
+		panels.Post("/:uid/versions/:version/restore", routing.Wrap(lps.restoreLibraryPanelVersionHandler))
+	})
+}
+
+func (lps *LibraryPanelService) getLibraryPanels(c *models.ReqContext) response.Response {
+	elements, err := lps.LibraryElementService.GetAllLibraryElements(c.OrgId, libraryelements.PanelElement)
+	if err != nil {
+		return response.Error(500, "failed to list library panels", err)
+	}
+
+	dtos := make([]LibraryPanelDTO, 0, len(elements))
+	for _, element := range elements {
+		g, err := newLibraryPanelGuardian(c, element.FolderID)
+		if err != nil {
+			return response.Error(500, "failed to resolve permissions", err)
+		}
+
+		canUse, err := g.CanUse()
+		if err != nil {
+			return response.Error(500, "failed to resolve permissions", err)
+		}
+		if !canUse {
+			continue
+		}
+
+		meta, err := lps.buildLibraryPanelDTOMeta(c, element.FolderID)
+		if err != nil {
+			return response.Error(500, "failed to resolve permissions", err)
+		}
+
+		dtos = append(dtos, LibraryPanelDTO{LibraryElement: element, Meta: meta})
+	}
+
+	return response.JSON(200, dtos)
+}
+
+func (lps *LibraryPanelService) getLibraryPanelByUID(c *models.ReqContext) response.Response {
+	element, err := lps.LibraryElementService.GetLibraryElement(c.OrgId, c.Params(":uid"))
+	if err != nil {
+		return response.Error(404, "library panel not found", err)
+	}
+
+	return lps.libraryPanelDTOResponse(c, element)
+}
+
+func (lps *LibraryPanelService) getLibraryPanelBySlugHandler(c *models.ReqContext) response.Response {
+	element, err := lps.GetLibraryPanelBySlug(c.OrgId, c.Params(":folder"), c.Params(":slug"))
+	if err != nil {
+		return response.Error(404, "library panel not found", err)
+	}
+
+	return lps.libraryPanelDTOResponse(c, element)
+}
+
+// libraryPanelDTOResponse checks that the caller may view element's folder, then returns it
+// wrapped in a LibraryPanelDTO carrying the caller's edit/admin permissions on that folder.
+func (lps *LibraryPanelService) libraryPanelDTOResponse(c *models.ReqContext, element libraryelements.LibraryElement) response.Response {
+	g, err := newLibraryPanelGuardian(c, element.FolderID)
+	if err != nil {
+		return response.Error(500, "failed to resolve permissions", err)
+	}
+
+	canUse, err := g.CanUse()
+	if err != nil {
+		return response.Error(500, "failed to resolve permissions", err)
+	}
+	if !canUse {
+		return response.Error(403, "access denied", libraryelements.ErrLibraryElementAccessDenied)
+	}
+
+	meta, err := lps.buildLibraryPanelDTOMeta(c, element.FolderID)
+	if err != nil {
+		return response.Error(500, "failed to resolve permissions", err)
+	}
+
+	return response.JSON(200, LibraryPanelDTO{LibraryElement: element, Meta: meta})
+}
+
+func (lps *LibraryPanelService) createLibraryPanel(c *models.ReqContext) response.Response {
+	var cmd libraryelements.CreateLibraryElementCommand
+	if err := json.NewDecoder(c.Req.Request.Body).Decode(&cmd); err != nil {
+		return response.Error(400, "bad request", err)
+	}
+	cmd.Kind = libraryelements.PanelElement
+
+	element, err := lps.LibraryElementService.CreateLibraryElement(c, cmd)
+	if err != nil {
+		return toLibraryPanelErrorResponse("failed to create library panel", err)
+	}
+
+	return response.JSON(200, element)
+}
+
+func (lps *LibraryPanelService) updateLibraryPanel(c *models.ReqContext) response.Response {
+	var cmd libraryelements.PatchLibraryElementCommand
+	if err := json.NewDecoder(c.Req.Request.Body).Decode(&cmd); err != nil {
+		return response.Error(400, "bad request", err)
+	}
+
+	element, err := lps.LibraryElementService.UpdateLibraryElement(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelErrorResponse("failed to update library panel", err)
+	}
+
+	return response.JSON(200, element)
+}
+
+func (lps *LibraryPanelService) deleteLibraryPanel(c *models.ReqContext) response.Response {
+	if err := lps.LibraryElementService.DeleteLibraryElement(c, c.Params(":uid")); err != nil {
+		return toLibraryPanelErrorResponse("failed to delete library panel", err)
+	}
+
+	return response.Success("library panel deleted")
+}
+
+// toLibraryPanelErrorResponse maps the sentinel errors a write operation can fail with
+// to their HTTP status code, falling back to 500 for anything else.
+func toLibraryPanelErrorResponse(message string, err error) response.Response {
+	switch err {
+	case libraryelements.ErrLibraryElementNotFound:
+		return response.Error(404, message, err)
+	case libraryelements.ErrLibraryElementAccessDenied:
+		return response.Error(403, message, err)
+	case libraryelements.ErrLibraryElementVersionMismatch:
+		return response.Error(409, message, err)
+	default:
+		return response.Error(500, message, err)
+	}
+}
+
+func (lps *LibraryPanelService) getLibraryPanelVersionsHandler(c *models.ReqContext) response.Response {
+	versions, err := lps.GetLibraryPanelVersions(c.OrgId, c.Params(":uid"))
+	if err != nil {
+		return response.Error(500, "failed to get library panel versions", err)
+	}
+
+	return response.JSON(200, versions)
+}
+
+func (lps *LibraryPanelService) getLibraryPanelVersionHandler(c *models.ReqContext) response.Response {
+	version, err := strconv.ParseInt(c.Params(":version"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid version", err)
+	}
+
+	v, err := lps.GetLibraryPanelVersion(c.OrgId, c.Params(":uid"), version)
+	if err != nil {
+		return response.Error(404, "library panel version not found", err)
+	}
+
+	return response.JSON(200, v)
+}
+
+func (lps *LibraryPanelService) getLibraryPanelVersionDiffHandler(c *models.ReqContext) response.Response {
+	base, err := strconv.ParseInt(c.Params(":base"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid base version", err)
+	}
+	target, err := strconv.ParseInt(c.Params(":target"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid target version", err)
+	}
+
+	patch, err := lps.GetLibraryPanelVersionDiff(c.OrgId, c.Params(":uid"), base, target)
+	if err != nil {
+		return response.Error(500, "failed to diff library panel versions", err)
+	}
+
+	return response.JSON(200, patch)
+}
+
+func (lps *LibraryPanelService) restoreLibraryPanelVersionHandler(c *models.ReqContext) response.Response {
+	version, err := strconv.ParseInt(c.Params(":version"), 10, 64)
+	if err != nil {
+		return response.Error(400, "invalid version", err)
+	}
+
+	element, err := lps.RestoreLibraryPanelVersion(c, c.Params(":uid"), version)
+	if err != nil {
+		return response.Error(500, "failed to restore library panel version", err)
+	}
+
+	return response.JSON(200, element)
+}
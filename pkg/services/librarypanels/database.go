@@ -0,0 +1,76 @@
+package librarypanels
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/libraryelements"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// getPinnedLibraryPanelVersionsForDashboard returns, for every library panel connection on
+// dash that is pinned to a specific version, the uid of the panel mapped to that version.
+func (lps *LibraryPanelService) getPinnedLibraryPanelVersionsForDashboard(dashboardID int64) (map[string]int64, error) {
+	var connections []struct {
+		UID     string
+		Version int64
+	}
+
+	err := lps.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		return sess.
+			Table("library_element_connection").
+			Join("INNER", "library_element", "library_element.id = library_element_connection.element_id").
+			Where("library_element_connection.connection_id = ? AND library_element_connection.kind = ? AND library_element_connection.version IS NOT NULL", dashboardID, libraryelements.PanelElement).
+			Cols("library_element.uid", "library_element_connection.version").
+			Find(&connections)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make(map[string]int64, len(connections))
+	for _, c := range connections {
+		pinned[c.UID] = c.Version
+	}
+
+	return pinned, nil
+}
+
+// applyPinnedLibraryPanelVersions overwrites the already-expanded library panel nodes in
+// dash with the model stored for their pinned version, instead of the latest one
+// LibraryElementService loaded them as.
+func (lps *LibraryPanelService) applyPinnedLibraryPanelVersions(dash *models.Dashboard, pinned map[string]int64) error {
+	panels := dash.Data.Get("panels").MustArray()
+	for i, panel := range panels {
+		panelAsJSON := simplejson.NewFromAny(panel)
+		libraryPanel := panelAsJSON.Get("libraryPanel")
+		if libraryPanel.Interface() == nil {
+			continue
+		}
+
+		uid := libraryPanel.Get("uid").MustString()
+		version, ok := pinned[uid]
+		if !ok {
+			continue
+		}
+
+		pinnedVersion, err := lps.LibraryElementService.GetLibraryElementVersion(dash.OrgId, uid, version)
+		if err != nil {
+			return err
+		}
+
+		dash.Data.Get("panels").SetIndex(i, pinnedVersion.Model.Interface())
+
+		elem := dash.Data.Get("panels").GetIndex(i)
+		elem.Set("gridPos", panelAsJSON.Get("gridPos").MustMap())
+		elem.Set("id", panelAsJSON.Get("id").MustInt64())
+		elem.Set("libraryPanel", map[string]interface{}{
+			"uid":     uid,
+			"name":    libraryPanel.Get("name").MustString(),
+			"version": version,
+		})
+	}
+
+	return nil
+}
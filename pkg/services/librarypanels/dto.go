@@ -0,0 +1,39 @@
+package librarypanels
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/libraryelements"
+)
+
+// LibraryPanelDTOMeta is embedded in GET responses so the frontend can hide actions the
+// caller isn't allowed to perform instead of finding out from a failed request.
+type LibraryPanelDTOMeta struct {
+	CanEdit  bool `json:"canEdit"`
+	CanAdmin bool `json:"canAdmin"`
+}
+
+// LibraryPanelDTO is a library panel plus the resolved meta block GET responses surface.
+type LibraryPanelDTO struct {
+	libraryelements.LibraryElement
+	Meta LibraryPanelDTOMeta `json:"meta"`
+}
+
+// buildLibraryPanelDTOMeta resolves the caller's guardian permissions for a library panel
+// stored in folderID into the meta block returned alongside the panel.
+func (lps *LibraryPanelService) buildLibraryPanelDTOMeta(c *models.ReqContext, folderID int64) (LibraryPanelDTOMeta, error) {
+	g, err := newLibraryPanelGuardian(c, folderID)
+	if err != nil {
+		return LibraryPanelDTOMeta{}, err
+	}
+
+	canEdit, err := g.CanEdit()
+	if err != nil {
+		return LibraryPanelDTOMeta{}, err
+	}
+	canAdmin, err := g.CanDelete()
+	if err != nil {
+		return LibraryPanelDTOMeta{}, err
+	}
+
+	return LibraryPanelDTOMeta{CanEdit: canEdit, CanAdmin: canAdmin}, nil
+}
@@ -0,0 +1,40 @@
+package librarypanels
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/libraryelements"
+)
+
+// LibraryPanelGuardian resolves the caller's permissions against the folder a library
+// panel lives in. It is kept as a thin backward-compatible shim over
+// libraryelements.LibraryElementGuardian, which resolves folder ACLs for every element
+// kind since the Panel Library was generalized into Library Elements.
+type LibraryPanelGuardian struct {
+	elementGuardian *libraryelements.LibraryElementGuardian
+}
+
+// newLibraryPanelGuardian builds a LibraryPanelGuardian for a library panel stored in folderID.
+func newLibraryPanelGuardian(c *models.ReqContext, folderID int64) (*LibraryPanelGuardian, error) {
+	g, err := libraryelements.NewLibraryElementGuardian(c, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LibraryPanelGuardian{elementGuardian: g}, nil
+}
+
+// CanUse returns true if the caller may read the library panel, i.e. view its folder.
+func (g *LibraryPanelGuardian) CanUse() (bool, error) {
+	return g.elementGuardian.CanUse()
+}
+
+// CanEdit returns true if the caller may create, connect or update the library panel,
+// i.e. edit its folder.
+func (g *LibraryPanelGuardian) CanEdit() (bool, error) {
+	return g.elementGuardian.CanEdit()
+}
+
+// CanDelete returns true if the caller may delete the library panel, i.e. admin its folder.
+func (g *LibraryPanelGuardian) CanDelete() (bool, error) {
+	return g.elementGuardian.CanDelete()
+}
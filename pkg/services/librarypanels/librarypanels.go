@@ -1,25 +1,26 @@
 package librarypanels
 
 import (
-	"errors"
-	"fmt"
-
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/libraryelements"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
-// LibraryPanelService is the service for the Panel Library feature.
+// LibraryPanelService is the service for the Panel Library feature. It is kept as a
+// thin backward-compatible shim over LibraryElementService, which replaced it as the
+// store backing library panels, rows, query definitions and template variables alike.
 type LibraryPanelService struct {
-	Cfg           *setting.Cfg          `inject:""`
-	SQLStore      *sqlstore.SQLStore    `inject:""`
-	RouteRegister routing.RouteRegister `inject:""`
-	log           log.Logger
+	Cfg                   *setting.Cfg                           `inject:""`
+	SQLStore              *sqlstore.SQLStore                     `inject:""`
+	RouteRegister         routing.RouteRegister                  `inject:""`
+	LibraryElementService *libraryelements.LibraryElementService `inject:""`
+	log                   log.Logger
 }
 
 func init() {
@@ -45,60 +46,28 @@ func (lps *LibraryPanelService) IsEnabled() bool {
 }
 
 // LoadLibraryPanelsForDashboard loads library panels JSON from the database for a dashboard.
+// It delegates to LibraryElementService, which is the store backing library panels since
+// the Panel Library was generalized into Library Elements, then re-applies any connection
+// pinned to a specific version so dashboards can opt out of always tracking latest.
 func (lps *LibraryPanelService) LoadLibraryPanelsForDashboard(dash *models.Dashboard) error {
 	if !lps.IsEnabled() {
 		return nil
 	}
 
-	libraryPanels, err := lps.getLibraryPanelsForDashboardID(dash.Id)
+	pinned, err := lps.getPinnedLibraryPanelVersionsForDashboard(dash.Id)
 	if err != nil {
 		return err
 	}
 
-	panels := dash.Data.Get("panels").MustArray()
-	for i, panel := range panels {
-		panelAsJSON := simplejson.NewFromAny(panel)
-		libraryPanel := panelAsJSON.Get("libraryPanel")
-		if libraryPanel.Interface() == nil {
-			continue
-		}
-
-		// we have a library panel
-		uid := libraryPanel.Get("uid").MustString()
-		if len(uid) == 0 {
-			return errors.New("found a library panel without uid")
-		}
-
-		libraryPanelInDB, ok := libraryPanels[uid]
-		if !ok {
-			return errors.New("found a library panel that does not exists as a connection")
-		}
-
-		// we have a match between what is stored in db and in dashboard json
-		libraryPanelModel, err := libraryPanelInDB.Model.MarshalJSON()
-		if err != nil {
-			return fmt.Errorf("could not marshal library panel JSON: %w", err)
-		}
-
-		libraryPanelModelAsJSON, err := simplejson.NewJson(libraryPanelModel)
-		if err != nil {
-			return fmt.Errorf("could not convert library panel to JSON: %w", err)
-		}
+	if err := lps.LibraryElementService.LoadLibraryElementsForDashboard(dash); err != nil {
+		return err
+	}
 
-		// set the library panel json as new panel json in dashboard json
-		dash.Data.Get("panels").SetIndex(i, libraryPanelModelAsJSON.Interface())
-
-		// set dashboard specific props
-		elem := dash.Data.Get("panels").GetIndex(i)
-		elem.Set("gridPos", panelAsJSON.Get("gridPos").MustMap())
-		elem.Set("id", panelAsJSON.Get("id").MustInt64())
-		elem.Set("libraryPanel", map[string]interface{}{
-			"uid":  libraryPanelInDB.UID,
-			"name": libraryPanelInDB.Name,
-		})
+	if len(pinned) == 0 {
+		return nil
 	}
 
-	return nil
+	return lps.applyPinnedLibraryPanelVersions(dash, pinned)
 }
 
 // CleanLibraryPanelsForDashboard cleans library panels JSON before storing a dashboard to the database.
@@ -107,49 +76,17 @@ func (lps *LibraryPanelService) CleanLibraryPanelsForDashboard(dash *models.Dash
 		return nil
 	}
 
-	panels := dash.Data.Get("panels").MustArray()
-	for i, panel := range panels {
-		panelAsJSON := simplejson.NewFromAny(panel)
-		libraryPanel := panelAsJSON.Get("libraryPanel")
-		if libraryPanel.Interface() == nil {
-			continue
-		}
-
-		// we have a library panel
-		uid := libraryPanel.Get("uid").MustString()
-		if len(uid) == 0 {
-			return errors.New("found a library panel without uid")
-		}
-		name := libraryPanel.Get("name").MustString()
-		if len(name) == 0 {
-			return errors.New("found a library panel without name")
-		}
-
-		gridPos := panelAsJSON.Get("gridPos").MustMap()
-		id := panelAsJSON.Get("id").MustInt64(int64(i))
-		dash.Data.Get("panels").SetIndex(i, map[string]interface{}{
-			"id":      id,
-			"gridPos": gridPos,
-			"libraryPanel": map[string]interface{}{
-				"uid":  uid,
-				"name": name,
-			},
-		})
-	}
-
-	return nil
+	return lps.LibraryElementService.CleanLibraryElementsForDashboard(dash)
 }
 
-// ConnectLibraryPanelsForDashboard connects library panels to a new dashboard.
+// ConnectLibraryPanelsForDashboard connects library panels to a new dashboard. Every
+// referenced library panel's folder is checked against the caller's permissions first;
+// Connect is rejected if the caller lacks edit rights on any one of them.
 func (lps *LibraryPanelService) ConnectLibraryPanelsForDashboard(c *models.ReqContext, dash *models.Dashboard) error {
 	if !lps.IsEnabled() {
 		return nil
 	}
 
-	if dash.Id == 0 || dash.Uid == "" {
-		return errors.New("dashboard is missing an ID or uid")
-	}
-
 	panels := dash.Data.Get("panels").MustArray()
 	for _, panel := range panels {
 		panelAsJSON := simplejson.NewFromAny(panel)
@@ -158,18 +95,37 @@ func (lps *LibraryPanelService) ConnectLibraryPanelsForDashboard(c *models.ReqCo
 			continue
 		}
 
-		// we have a library panel
 		uid := libraryPanel.Get("uid").MustString()
 		if len(uid) == 0 {
-			return errors.New("found a library panel without uid")
+			continue
 		}
-		err := lps.connectDashboard(c, uid, dash.Id)
+
+		canEdit, err := lps.canEditLibraryPanel(c, uid)
 		if err != nil {
 			return err
 		}
+		if !canEdit {
+			return libraryelements.ErrLibraryElementAccessDenied
+		}
 	}
 
-	return nil
+	return lps.LibraryElementService.ConnectLibraryElementsForDashboard(c, dash)
+}
+
+// canEditLibraryPanel resolves the library panel's folder and asks a LibraryPanelGuardian
+// whether the caller may edit it.
+func (lps *LibraryPanelService) canEditLibraryPanel(c *models.ReqContext, uid string) (bool, error) {
+	panel, err := lps.LibraryElementService.GetLibraryElement(c.OrgId, uid)
+	if err != nil {
+		return false, err
+	}
+
+	g, err := newLibraryPanelGuardian(c, panel.FolderID)
+	if err != nil {
+		return false, err
+	}
+
+	return g.CanEdit()
 }
 
 // AddMigration defines database migrations.
@@ -217,4 +173,36 @@ func (lps *LibraryPanelService) AddMigration(mg *migrator.Migrator) {
 
 	mg.AddMigration("create library_panel_dashboard table v1", migrator.NewAddTableMigration(libraryPanelDashboardV1))
 	mg.AddMigration("add index library_panel_dashboard librarypanel_id & dashboard_id", migrator.NewAddIndexMigration(libraryPanelDashboardV1, libraryPanelDashboardV1.Indices[0]))
+
+	// Version history and the version pin on a dashboard connection live on
+	// library_element_version/library_element_connection now; see LibraryElementService.AddMigration.
+}
+
+// GetLibraryPanelBySlug gets a library panel by its human-readable slug within folderUID,
+// delegating to LibraryElementService, which owns slug lookup and disambiguation for every
+// element kind since the Panel Library was generalized into Library Elements.
+func (lps *LibraryPanelService) GetLibraryPanelBySlug(orgID int64, folderUID, slug string) (libraryelements.LibraryElement, error) {
+	return lps.LibraryElementService.GetLibraryElementBySlugAndFolderUID(orgID, folderUID, libraryelements.PanelElement, slug)
+}
+
+// GetLibraryPanelVersions gets every archived revision of a library panel, newest first.
+// The current version is not included.
+func (lps *LibraryPanelService) GetLibraryPanelVersions(orgID int64, uid string) ([]libraryelements.LibraryElementVersion, error) {
+	return lps.LibraryElementService.GetLibraryElementVersions(orgID, uid)
+}
+
+// GetLibraryPanelVersion gets a single stored revision of a library panel.
+func (lps *LibraryPanelService) GetLibraryPanelVersion(orgID int64, uid string, version int64) (libraryelements.LibraryElementVersion, error) {
+	return lps.LibraryElementService.GetLibraryElementVersion(orgID, uid, version)
+}
+
+// RestoreLibraryPanelVersion restores a library panel to a previously stored revision,
+// recording the restore itself as a new version so history is never rewritten.
+func (lps *LibraryPanelService) RestoreLibraryPanelVersion(c *models.ReqContext, uid string, version int64) (libraryelements.LibraryElement, error) {
+	return lps.LibraryElementService.RestoreLibraryElementVersion(c, uid, version)
+}
+
+// GetLibraryPanelVersionDiff returns a JSON patch between two stored revisions of a library panel.
+func (lps *LibraryPanelService) GetLibraryPanelVersionDiff(orgID int64, uid string, base, target int64) ([]libraryelements.PatchOp, error) {
+	return lps.LibraryElementService.GetLibraryElementVersionDiff(orgID, uid, base, target)
 }